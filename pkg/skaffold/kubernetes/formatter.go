@@ -0,0 +1,122 @@
+/*
+Copyright 2018 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// PodInfo identifies the pod/container/namespace a log line came from.
+type PodInfo struct {
+	Namespace string
+	Pod       string
+	Container string
+}
+
+// Formatter turns a single log line from a pod/container into the bytes
+// written to the aggregator's output.
+type Formatter interface {
+	Format(info PodInfo, line []byte) []byte
+}
+
+// NewFormatter picks a colorFormatter when out is a terminal and colors
+// haven't been disabled via NO_COLOR, falling back to plainFormatter
+// otherwise - ANSI codes just add noise to redirected or piped output.
+func NewFormatter(out io.Writer) Formatter {
+	if os.Getenv("NO_COLOR") != "" {
+		return plainFormatter{}
+	}
+	f, ok := out.(*os.File)
+	if !ok || !term.IsTerminal(int(f.Fd())) {
+		return plainFormatter{}
+	}
+	return colorFormatter{}
+}
+
+// plainFormatter reproduces the aggregator's original "[pod container] line" prefix.
+type plainFormatter struct{}
+
+func (plainFormatter) Format(info PodInfo, line []byte) []byte {
+	header := fmt.Sprintf("[%s %s] ", info.Pod, info.Container)
+	return append([]byte(header), line...)
+}
+
+// colorFormatter assigns each pod a stable ANSI color, picked by hashing
+// the pod name, so interleaved logs from different pods are easy to tell
+// apart.
+type colorFormatter struct {
+	plain plainFormatter
+}
+
+// podColors are foreground ANSI codes chosen to be readable on both light
+// and dark terminal backgrounds.
+var podColors = []int{31, 32, 33, 34, 35, 36, 91, 92, 93, 94, 95, 96}
+
+func (f colorFormatter) Format(info PodInfo, line []byte) []byte {
+	formatted := f.plain.Format(info, line)
+	code := podColors[hashString(info.Pod)%uint32(len(podColors))]
+	return []byte(fmt.Sprintf("\033[%dm%s\033[0m", code, formatted))
+}
+
+func hashString(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
+
+// NewJSONFormatter returns a Formatter emitting one JSON object per line,
+// for users running Skaffold under a log shipper that wants structured
+// output instead of the human-readable colored/plain prefix.
+func NewJSONFormatter() Formatter {
+	return jsonFormatter{}
+}
+
+// jsonFormatter emits one JSON object per line, for users running Skaffold
+// under a log shipper that wants structured output.
+type jsonFormatter struct{}
+
+type jsonLogLine struct {
+	Namespace string `json:"namespace"`
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	Time      string `json:"time"`
+	Message   string `json:"message"`
+}
+
+func (jsonFormatter) Format(info PodInfo, line []byte) []byte {
+	record := jsonLogLine{
+		Namespace: info.Namespace,
+		Pod:       info.Pod,
+		Container: info.Container,
+		Time:      time.Now().UTC().Format(time.RFC3339Nano),
+		Message:   string(bytes.TrimRight(line, "\n")),
+	}
+
+	out, err := json.Marshal(record)
+	if err != nil {
+		return line
+	}
+	return append(out, '\n')
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2018 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestPlainFormatter(t *testing.T) {
+	info := PodInfo{Pod: "web-1", Container: "app"}
+	got := string(plainFormatter{}.Format(info, []byte("hello\n")))
+	want := "[web-1 app] hello\n"
+	if got != want {
+		t.Errorf("Format() = %q, want %q", got, want)
+	}
+}
+
+func TestColorFormatterWrapsPlainOutput(t *testing.T) {
+	info := PodInfo{Pod: "web-1", Container: "app"}
+	plain := string(plainFormatter{}.Format(info, []byte("hello\n")))
+	got := string(colorFormatter{}.Format(info, []byte("hello\n")))
+
+	if !strings.Contains(got, plain) {
+		t.Errorf("Format() = %q, want it to contain the plain-formatted %q", got, plain)
+	}
+	if !strings.HasPrefix(got, "\033[") {
+		t.Errorf("Format() = %q, want it to start with an ANSI escape code", got)
+	}
+}
+
+func TestHashStringIsStableAndSpreadsAcrossColors(t *testing.T) {
+	if hashString("pod-a") != hashString("pod-a") {
+		t.Error("hashString() should be deterministic for the same input")
+	}
+
+	seen := make(map[uint32]bool)
+	for _, pod := range []string{"pod-a", "pod-b", "pod-c", "pod-d"} {
+		seen[hashString(pod)%uint32(len(podColors))] = true
+	}
+	if len(seen) < 2 {
+		t.Error("hashString() mod len(podColors) should spread distinct pod names across more than one color")
+	}
+}
+
+func TestJSONFormatter(t *testing.T) {
+	info := PodInfo{Namespace: "ns", Pod: "web-1", Container: "app"}
+	out := jsonFormatter{}.Format(info, []byte("hello\n"))
+
+	var record jsonLogLine
+	if err := json.Unmarshal(out, &record); err != nil {
+		t.Fatalf("Format() produced invalid JSON: %v", err)
+	}
+	if record.Namespace != "ns" || record.Pod != "web-1" || record.Container != "app" || record.Message != "hello" {
+		t.Errorf("Format() = %+v, want namespace/pod/container/message to match the input PodInfo and trimmed line", record)
+	}
+}
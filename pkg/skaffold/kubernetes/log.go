@@ -18,39 +18,56 @@ package kubernetes
 
 import (
 	"bufio"
-	"fmt"
+	"context"
 	"io"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	restclient "k8s.io/client-go/rest"
 )
 
-const defaultRetry int = 5
-
 // LogAggregator aggregates the logs for all the deployed pods.
 type LogAggregator struct {
 	Muter
 
 	creationTime time.Time
 	output       io.Writer
-	retries      int
+	outputLock   sync.Mutex
+	selector     LogSelector
+	formatter    Formatter
+
+	streamsLock   sync.Mutex
+	activeStreams map[string]context.CancelFunc
 }
 
-// NewLogAggregator creates a new LogAggregator for a given output.
-func NewLogAggregator(out io.Writer) *LogAggregator {
+// NewLogAggregator creates a new LogAggregator for a given output, streaming
+// logs for whatever pods and containers selector matches. It formats lines
+// with NewFormatter(out) by default; use SetFormatter to override, e.g. to
+// force JSON output for a log shipper.
+func NewLogAggregator(out io.Writer, selector LogSelector) *LogAggregator {
 	return &LogAggregator{
-		creationTime: time.Now(),
-		output:       out,
-		retries:      defaultRetry,
+		creationTime:  time.Now(),
+		output:        out,
+		selector:      selector,
+		formatter:     NewFormatter(out),
+		activeStreams: make(map[string]context.CancelFunc),
 	}
 }
 
+// SetFormatter overrides the formatter used for streamed log lines.
+func (a *LogAggregator) SetFormatter(f Formatter) {
+	a.formatter = f
+}
+
 const streamRetryDelay = 1 * time.Second
 
 // TODO(@r2d4): Figure out how to mock this out. fake.NewSimpleClient
@@ -59,62 +76,178 @@ var getStream = func(r *restclient.Request) (io.ReadCloser, error) {
 	return r.Stream()
 }
 
-func (a *LogAggregator) StreamLogs(client corev1.CoreV1Interface, image string) {
-	for i := 0; i < a.retries; i++ {
-		if err := a.streamLogs(client, image); err != nil {
-			logrus.Infof("Error getting logs %s", err)
-		}
-		time.Sleep(streamRetryDelay)
+// StreamLogs watches for pods matching the aggregator's selector and
+// streams their logs until ctx is done. Streams are started as matching
+// pods become ready and torn down as soon as the pod they belong to is
+// deleted, so there's no fixed retry budget to exhaust.
+func (a *LogAggregator) StreamLogs(ctx context.Context, client corev1.CoreV1Interface) error {
+	var listOptions meta_v1.ListOptions
+	if a.selector.LabelSelector != nil {
+		listOptions.LabelSelector = a.selector.LabelSelector.String()
 	}
-}
 
-// nolint: interfacer
-func (a *LogAggregator) streamLogs(client corev1.CoreV1Interface, image string) error {
-	pods, err := client.Pods("").List(meta_v1.ListOptions{
-		IncludeUninitialized: true,
-	})
+	watcher := NewPodWatcher(client, a.selector.Namespaces, listOptions)
+	events, err := watcher.Start()
 	if err != nil {
-		return errors.Wrap(err, "getting pods")
+		return errors.Wrap(err, "starting pod watcher")
 	}
+	defer watcher.Stop()
 
-	logrus.Infof("Looking for logs to stream for %s", image)
-	for _, p := range pods.Items {
+	logrus.Info("Looking for logs to stream")
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			a.handlePodEvent(ctx, client, event)
+		}
+	}
+}
+
+// handlePodEvent starts or cancels streams for the (pod, container) pairs
+// matched by the selector in response to a single watch event.
+func (a *LogAggregator) handlePodEvent(ctx context.Context, client corev1.CoreV1Interface, event PodEvent) {
+	p := event.Pod
+
+	switch event.Type {
+	case watch.Deleted:
 		for _, c := range p.Spec.Containers {
+			a.stopStream(streamKey(p.UID, c.Name))
+		}
+
+	case watch.Added, watch.Modified:
+		if !isPodReady(p) {
+			return
+		}
+		for _, c := range p.Spec.Containers {
+			c := c
 			logrus.Debugf("Found container %s with image %s", c.Name, c.Image)
-			if c.Image != image {
+			if !a.selector.matchesContainer(c) {
 				continue
 			}
-
-			logrus.Infof("Trying to stream logs from pod: %s container: %s", p.Name, c.Name)
-			pods := client.Pods(p.Namespace)
-			if err := WaitForPodReady(pods, p.Name); err != nil {
-				return errors.Wrap(err, "waiting for pod ready")
-			}
-			req := pods.GetLogs(p.Name, &v1.PodLogOptions{
-				Follow:    true,
-				Container: c.Name,
-				SinceTime: &meta_v1.Time{
-					Time: a.creationTime,
-				},
-			})
-			rc, err := getStream(req)
-			if err != nil {
-				return errors.Wrap(err, "setting up container log stream")
+			if isContainerDead(p, c.Name) {
+				continue
 			}
-			defer rc.Close()
-			header := fmt.Sprintf("[%s %s]", p.Name, c.Name)
-			if err := a.streamRequest(header, rc); err != nil {
-				return errors.Wrap(err, "streaming request")
+
+			key := streamKey(p.UID, c.Name)
+			streamCtx, cancel := context.WithCancel(ctx)
+			if !a.startStream(key, cancel) {
+				cancel()
+				continue
 			}
 
+			logrus.Infof("Trying to stream logs from pod: %s container: %s", p.Name, c.Name)
+			go func(p v1.Pod, c v1.Container) {
+				defer a.stopStream(key)
+				if err := a.streamContainerLogs(streamCtx, client, p, c); err != nil && streamCtx.Err() == nil {
+					logrus.Infof("Error streaming logs from pod %s container %s: %s", p.Name, c.Name, err)
+				}
+			}(*p, c)
+		}
+	}
+}
+
+// streamKey identifies a single pod/container log stream so a pod that's
+// re-reported by the watcher doesn't start a second goroutine for a
+// container that's already streaming.
+func streamKey(podUID types.UID, container string) string {
+	return string(podUID) + "/" + container
+}
+
+func (a *LogAggregator) startStream(key string, cancel context.CancelFunc) bool {
+	a.streamsLock.Lock()
+	defer a.streamsLock.Unlock()
+
+	if _, ok := a.activeStreams[key]; ok {
+		return false
+	}
+	a.activeStreams[key] = cancel
+	return true
+}
+
+// stopStream cancels the stream for key, if any, and forgets about it so a
+// later ADDED/MODIFIED event can start it again.
+func (a *LogAggregator) stopStream(key string) {
+	a.streamsLock.Lock()
+	cancel, ok := a.activeStreams[key]
+	delete(a.activeStreams, key)
+	a.streamsLock.Unlock()
+
+	if ok {
+		cancel()
+	}
+}
+
+func isPodReady(p *v1.Pod) bool {
+	for _, cond := range p.Status.Conditions {
+		if cond.Type == v1.PodReady {
+			return cond.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// isContainerDead reports whether a container has terminated without ever
+// restarting, meaning it's never going to produce more logs and retrying
+// it would just be wasted work.
+func isContainerDead(p *v1.Pod, container string) bool {
+	for _, cs := range p.Status.ContainerStatuses {
+		if cs.Name == container {
+			return cs.State.Terminated != nil && cs.RestartCount == 0
+		}
+	}
+	return false
+}
+
+// isRecoverable tells apart a transient streaming error (network blip, pod
+// restarting) from a fatal one (forbidden, pod gone), so we only pay the
+// streamRetryDelay when reconnecting stands a chance of working.
+func isRecoverable(err error) bool {
+	return !apierrors.IsForbidden(err) && !apierrors.IsNotFound(err)
+}
+
+func (a *LogAggregator) streamContainerLogs(ctx context.Context, client corev1.CoreV1Interface, p v1.Pod, c v1.Container) error {
+	for {
+		err := a.streamContainerLogsOnce(ctx, client, p, c)
+		if err == nil || ctx.Err() != nil || !isRecoverable(errors.Cause(err)) {
+			return err
+		}
+
+		logrus.Infof("Reconnecting logs for pod %s container %s: %s", p.Name, c.Name, err)
+		select {
+		case <-time.After(streamRetryDelay):
+		case <-ctx.Done():
 			return nil
 		}
 	}
+}
 
-	return fmt.Errorf("Image %s not found", image)
+func (a *LogAggregator) streamContainerLogsOnce(ctx context.Context, client corev1.CoreV1Interface, p v1.Pod, c v1.Container) error {
+	pods := client.Pods(p.Namespace)
+	req := pods.GetLogs(p.Name, &v1.PodLogOptions{
+		Follow:    true,
+		Container: c.Name,
+		SinceTime: &meta_v1.Time{
+			Time: a.creationTime,
+		},
+	}).Context(ctx)
+	rc, err := getStream(req)
+	if err != nil {
+		return errors.Wrap(err, "setting up container log stream")
+	}
+	defer rc.Close()
+
+	info := PodInfo{Namespace: p.Namespace, Pod: p.Name, Container: c.Name}
+	return a.streamRequest(info, rc)
 }
 
-func (a *LogAggregator) streamRequest(header string, rc io.Reader) error {
+// streamRequest reads full lines (buffering until '\n') and hands each one
+// to the formatter, writing the result under outputLock so concurrent
+// streams from other pods/containers can't interleave partial lines.
+func (a *LogAggregator) streamRequest(info PodInfo, rc io.Reader) error {
 	r := bufio.NewReader(rc)
 	for {
 		// Read up to newline
@@ -129,12 +262,16 @@ func (a *LogAggregator) streamRequest(header string, rc io.Reader) error {
 		if a.IsMuted() {
 			continue
 		}
+		formatted := a.formatter.Format(info, line)
 
-		if _, err := fmt.Fprintf(a.output, "%s %s", header, line); err != nil {
+		a.outputLock.Lock()
+		_, err = a.output.Write(formatted)
+		a.outputLock.Unlock()
+		if err != nil {
 			return errors.Wrap(err, "writing to out")
 		}
 	}
-	logrus.Infof("%s exited", header)
+	logrus.Infof("[%s %s] exited", info.Pod, info.Container)
 	return nil
 }
 
@@ -0,0 +1,149 @@
+/*
+Copyright 2018 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/pkg/errors"
+	"k8s.io/api/core/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// DumpOptions configures LogAggregator.DumpLogs.
+type DumpOptions struct {
+	// Previous, when the container has restarted, also dumps its previous
+	// instance's logs to a "<namespace>_<pod>_<container>.previous.log"
+	// sibling file.
+	Previous bool
+	// GrepRegex, when set, only writes lines it matches.
+	GrepRegex *regexp.Regexp
+	// MaxBytes caps the size of each written log file, if > 0.
+	MaxBytes int64
+}
+
+// DumpLogs walks every pod/container matching the aggregator's selector and
+// writes its logs to dir, one file per container, so CI can collect a
+// `skaffold logs --dump` artifact for post-mortem debugging without
+// keeping a stream open. It shares the pod-discovery/selector walk with
+// Read via forEachMatchingContainer; StreamLogs discovers pods through
+// PodWatcher instead, so there's nothing to share with it.
+func (a *LogAggregator) DumpLogs(ctx context.Context, client corev1.CoreV1Interface, dir string, opts DumpOptions) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, "creating dump directory")
+	}
+
+	return forEachMatchingContainer(client, a.selector, func(p v1.Pod, c v1.Container) error {
+		path := filepath.Join(dir, fmt.Sprintf("%s_%s_%s.log", p.Namespace, p.Name, c.Name))
+		if err := dumpContainerLogs(ctx, client, p, c, path, false, opts); err != nil {
+			return errors.Wrapf(err, "dumping logs for pod %s container %s", p.Name, c.Name)
+		}
+
+		if opts.Previous && hasRestarted(p, c.Name) {
+			previousPath := filepath.Join(dir, fmt.Sprintf("%s_%s_%s.previous.log", p.Namespace, p.Name, c.Name))
+			if err := dumpContainerLogs(ctx, client, p, c, previousPath, true, opts); err != nil {
+				return errors.Wrapf(err, "dumping previous logs for pod %s container %s", p.Name, c.Name)
+			}
+		}
+		return nil
+	})
+}
+
+func hasRestarted(p v1.Pod, container string) bool {
+	for _, cs := range p.Status.ContainerStatuses {
+		if cs.Name == container {
+			return cs.RestartCount > 0
+		}
+	}
+	return false
+}
+
+func dumpContainerLogs(ctx context.Context, client corev1.CoreV1Interface, p v1.Pod, c v1.Container, path string, previous bool, opts DumpOptions) error {
+	req := client.Pods(p.Namespace).GetLogs(p.Name, &v1.PodLogOptions{
+		Container: c.Name,
+		Previous:  previous,
+	}).Context(ctx)
+	rc, err := getStream(req)
+	if err != nil {
+		return errors.Wrap(err, "setting up container log stream")
+	}
+	defer rc.Close()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, "creating dump file")
+	}
+	defer f.Close()
+
+	bw := bufio.NewWriter(f)
+	var w io.Writer = bw
+	if opts.MaxBytes > 0 {
+		w = &limitedWriter{w: bw, remaining: opts.MaxBytes}
+	}
+
+	scanner := bufio.NewScanner(rc)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if opts.GrepRegex != nil && !opts.GrepRegex.Match(line) {
+			continue
+		}
+
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			if err == errMaxBytesReached {
+				break
+			}
+			return errors.Wrap(err, "writing dump file")
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return errors.Wrap(err, "reading log stream")
+	}
+	return errors.Wrap(bw.Flush(), "flushing dump file")
+}
+
+// errMaxBytesReached signals limitedWriter hit its cap; dumpContainerLogs
+// treats it as "stop writing this file", not a real error.
+var errMaxBytesReached = errors.New("max bytes reached")
+
+// limitedWriter caps the total number of bytes written to w.
+type limitedWriter struct {
+	w         io.Writer
+	remaining int64
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.remaining <= 0 {
+		return 0, errMaxBytesReached
+	}
+	if int64(len(p)) > w.remaining {
+		p = p[:w.remaining]
+	}
+
+	n, err := w.w.Write(p)
+	w.remaining -= int64(n)
+	if err == nil && w.remaining <= 0 {
+		err = errMaxBytesReached
+	}
+	return n, err
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2018 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	restclient "k8s.io/client-go/rest"
+)
+
+func TestLimitedWriterTruncatesAtTheCap(t *testing.T) {
+	var buf bytes.Buffer
+	w := &limitedWriter{w: &buf, remaining: 5}
+
+	n, err := w.Write([]byte("hello world"))
+	if n != 5 {
+		t.Errorf("Write() wrote %d bytes, want 5 (the remaining cap)", n)
+	}
+	if err != errMaxBytesReached {
+		t.Errorf("Write() error = %v, want errMaxBytesReached", err)
+	}
+	if buf.String() != "hello" {
+		t.Errorf("buffer = %q, want %q", buf.String(), "hello")
+	}
+}
+
+func TestLimitedWriterStopsOnceExhausted(t *testing.T) {
+	var buf bytes.Buffer
+	w := &limitedWriter{w: &buf, remaining: 5}
+
+	if _, err := w.Write([]byte("hello")); err != errMaxBytesReached {
+		t.Fatalf("first Write() error = %v, want errMaxBytesReached once the cap is exactly hit", err)
+	}
+
+	n, err := w.Write([]byte("more"))
+	if n != 0 || err != errMaxBytesReached {
+		t.Errorf("Write() after the cap is hit = (%d, %v), want (0, errMaxBytesReached)", n, err)
+	}
+	if buf.String() != "hello" {
+		t.Errorf("buffer = %q, want no further bytes written", buf.String())
+	}
+}
+
+func TestLimitedWriterUnderCap(t *testing.T) {
+	var buf bytes.Buffer
+	w := &limitedWriter{w: &buf, remaining: 100}
+
+	n, err := w.Write([]byte("hello"))
+	if n != 5 || err != nil {
+		t.Errorf("Write() = (%d, %v), want (5, nil)", n, err)
+	}
+	if buf.String() != "hello" {
+		t.Errorf("buffer = %q, want %q", buf.String(), "hello")
+	}
+}
+
+func TestHasRestarted(t *testing.T) {
+	pod := v1.Pod{Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{
+		{Name: "restarted", RestartCount: 2},
+		{Name: "fresh", RestartCount: 0},
+	}}}
+
+	if !hasRestarted(pod, "restarted") {
+		t.Error("hasRestarted() = false for a container with RestartCount > 0, want true")
+	}
+	if hasRestarted(pod, "fresh") {
+		t.Error("hasRestarted() = true for a container with RestartCount 0, want false")
+	}
+	if hasRestarted(pod, "unknown") {
+		t.Error("hasRestarted() = true for a container with no status, want false")
+	}
+}
+
+// stubGetStream returns the next reader in responses on each call, in
+// order, standing in for the sequence of current/previous log streams
+// dumpContainerLogs requests.
+func stubGetStream(t *testing.T, responses ...string) {
+	t.Helper()
+	restore := getStream
+	i := 0
+	getStream = func(r *restclient.Request) (io.ReadCloser, error) {
+		if i >= len(responses) {
+			t.Fatalf("getStream called %d times, only %d stubbed responses", i+1, len(responses))
+		}
+		resp := responses[i]
+		i++
+		return io.NopCloser(strings.NewReader(resp)), nil
+	}
+	t.Cleanup(func() { getStream = restore })
+}
+
+func TestDumpContainerLogsAppliesGrepAndMaxBytes(t *testing.T) {
+	stubGetStream(t, "INFO start\nERROR boom\nINFO mid\nERROR again\n")
+
+	client := fake.NewSimpleClientset(&v1.Pod{ObjectMeta: meta_v1.ObjectMeta{Name: "pod-a", Namespace: "ns"}})
+	path := filepath.Join(t.TempDir(), "out.log")
+
+	opts := DumpOptions{GrepRegex: regexp.MustCompile("^ERROR"), MaxBytes: 8}
+	pod := v1.Pod{ObjectMeta: meta_v1.ObjectMeta{Name: "pod-a", Namespace: "ns"}}
+	if err := dumpContainerLogs(context.Background(), client.CoreV1(), pod, v1.Container{Name: "app"}, path, false, opts); err != nil {
+		t.Fatalf("dumpContainerLogs() error = %v", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading dump file: %v", err)
+	}
+	// Only the two ERROR lines match the grep filter, and MaxBytes=8 caps
+	// the file to the first 8 bytes of that filtered (not raw) output.
+	if want := "ERROR bo"; string(got) != want {
+		t.Errorf("dump file = %q, want %q", string(got), want)
+	}
+}
+
+func TestDumpLogsWritesPreviousLogOnlyWhenRestarted(t *testing.T) {
+	stubGetStream(t, "current logs\n", "previous logs\n")
+
+	client := fake.NewSimpleClientset(&v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "pod-a", Namespace: "ns"},
+		Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "app"}}},
+		Status:     v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{{Name: "app", RestartCount: 1}}},
+	})
+
+	a := NewLogAggregator(io.Discard, LogSelector{})
+	dir := t.TempDir()
+	if err := a.DumpLogs(context.Background(), client.CoreV1(), dir, DumpOptions{Previous: true}); err != nil {
+		t.Fatalf("DumpLogs() error = %v", err)
+	}
+
+	current, err := os.ReadFile(filepath.Join(dir, "ns_pod-a_app.log"))
+	if err != nil {
+		t.Fatalf("reading current dump file: %v", err)
+	}
+	if string(current) != "current logs\n" {
+		t.Errorf("current dump file = %q, want %q", string(current), "current logs\n")
+	}
+
+	previous, err := os.ReadFile(filepath.Join(dir, "ns_pod-a_app.previous.log"))
+	if err != nil {
+		t.Fatalf("reading previous dump file: %v", err)
+	}
+	if string(previous) != "previous logs\n" {
+		t.Errorf("previous dump file = %q, want %q", string(previous), "previous logs\n")
+	}
+}
+
+func TestDumpLogsSkipsPreviousLogWhenNotRestarted(t *testing.T) {
+	stubGetStream(t, "current logs\n")
+
+	client := fake.NewSimpleClientset(&v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "pod-a", Namespace: "ns"},
+		Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "app"}}},
+		Status:     v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{{Name: "app", RestartCount: 0}}},
+	})
+
+	a := NewLogAggregator(io.Discard, LogSelector{})
+	dir := t.TempDir()
+	if err := a.DumpLogs(context.Background(), client.CoreV1(), dir, DumpOptions{Previous: true}); err != nil {
+		t.Fatalf("DumpLogs() error = %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "ns_pod-a_app.previous.log")); !os.IsNotExist(err) {
+		t.Errorf("previous.log should not be written for a container that never restarted, stat err = %v", err)
+	}
+}
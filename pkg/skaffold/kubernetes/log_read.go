@@ -0,0 +1,180 @@
+/*
+Copyright 2018 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"bufio"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// ReadOptions configures a historical log read via LogAggregator.Read, as
+// opposed to the continuous, watch-driven StreamLogs.
+type ReadOptions struct {
+	// Since returns logs newer than this duration ago. Ignored if SinceTime is set.
+	Since time.Duration
+	// SinceTime returns logs newer than this absolute time.
+	SinceTime time.Time
+	// TailLines limits the output to the last N lines, if > 0.
+	TailLines int64
+	// Timestamps prefixes every line with its RFC3339Nano timestamp, which
+	// Read parses back out into LogRecord.Timestamp.
+	Timestamps bool
+	// Previous reads the logs of the previous terminated container instance.
+	Previous bool
+	// Follow keeps the read open and streams new lines as they arrive.
+	Follow bool
+}
+
+// LogRecord is a single log line returned by LogAggregator.Read.
+type LogRecord struct {
+	Namespace string
+	Pod       string
+	Container string
+	Timestamp time.Time
+	Message   string
+}
+
+// Read lists every pod/container matching the aggregator's selector and
+// returns their logs according to opts. Unlike StreamLogs, it doesn't
+// retry or watch for new pods — it's meant for a one-shot dump of history
+// (e.g. on skaffold dev startup) or, with opts.Follow, a single long-lived
+// read that ctx can cancel.
+//
+// With opts.Follow, every matching container is read concurrently, since
+// each read blocks for as long as the container keeps producing logs; a
+// sequential loop would never get past the first match. Canceling ctx
+// ends the follow and Read returns whatever records were collected before
+// cancellation, not an error — that's the documented way to stop it.
+func (a *LogAggregator) Read(ctx context.Context, client corev1.CoreV1Interface, opts ReadOptions) ([]LogRecord, error) {
+	if !opts.Follow {
+		var records []LogRecord
+		err := forEachMatchingContainer(client, a.selector, func(p v1.Pod, c v1.Container) error {
+			containerRecords, err := readContainerLogs(ctx, client, p, c, opts)
+			if err != nil {
+				return errors.Wrapf(err, "reading logs for pod %s container %s", p.Name, c.Name)
+			}
+			records = append(records, containerRecords...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return records, nil
+	}
+
+	var (
+		recordsLock sync.Mutex
+		records     []LogRecord
+		wg          sync.WaitGroup
+		firstErr    error
+	)
+	err := forEachMatchingContainer(client, a.selector, func(p v1.Pod, c v1.Container) error {
+		wg.Add(1)
+		go func(p v1.Pod, c v1.Container) {
+			defer wg.Done()
+			containerRecords, err := readContainerLogs(ctx, client, p, c, opts)
+
+			recordsLock.Lock()
+			defer recordsLock.Unlock()
+			records = append(records, containerRecords...)
+			if err != nil && firstErr == nil {
+				firstErr = errors.Wrapf(err, "reading logs for pod %s container %s", p.Name, c.Name)
+			}
+		}(p, c)
+		return nil
+	})
+	wg.Wait()
+	if err != nil {
+		return records, err
+	}
+	return records, firstErr
+}
+
+func readContainerLogs(ctx context.Context, client corev1.CoreV1Interface, p v1.Pod, c v1.Container, opts ReadOptions) ([]LogRecord, error) {
+	logOptions := &v1.PodLogOptions{
+		Container:  c.Name,
+		Timestamps: opts.Timestamps,
+		Previous:   opts.Previous,
+		Follow:     opts.Follow,
+	}
+	switch {
+	case !opts.SinceTime.IsZero():
+		logOptions.SinceTime = &meta_v1.Time{Time: opts.SinceTime}
+	case opts.Since > 0:
+		seconds := int64(opts.Since.Seconds())
+		logOptions.SinceSeconds = &seconds
+	}
+	if opts.TailLines > 0 {
+		tailLines := opts.TailLines
+		logOptions.TailLines = &tailLines
+	}
+
+	req := client.Pods(p.Namespace).GetLogs(p.Name, logOptions).Context(ctx)
+	rc, err := getStream(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "setting up container log stream")
+	}
+	defer rc.Close()
+
+	var records []LogRecord
+	scanner := bufio.NewScanner(rc)
+	for scanner.Scan() {
+		records = append(records, parseLogRecord(p.Namespace, p.Name, c.Name, scanner.Text(), opts.Timestamps))
+	}
+	if err := scanner.Err(); err != nil {
+		if ctx.Err() != nil {
+			// ctx was canceled to end a Follow read; that's expected, so
+			// return what was collected instead of treating it as a failure.
+			return records, nil
+		}
+		return nil, errors.Wrap(err, "reading log stream")
+	}
+
+	return records, nil
+}
+
+// parseLogRecord splits the "<timestamp> <message>" prefix Kubernetes adds
+// when PodLogOptions.Timestamps is set back into its two parts.
+func parseLogRecord(namespace, pod, container, line string, hasTimestamp bool) LogRecord {
+	record := LogRecord{
+		Namespace: namespace,
+		Pod:       pod,
+		Container: container,
+		Message:   line,
+	}
+	if !hasTimestamp {
+		return record
+	}
+
+	parts := strings.SplitN(line, " ", 2)
+	if len(parts) != 2 {
+		return record
+	}
+	if ts, err := time.Parse(time.RFC3339Nano, parts[0]); err == nil {
+		record.Timestamp = ts
+		record.Message = parts[1]
+	}
+	return record
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2018 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	restclient "k8s.io/client-go/rest"
+)
+
+func TestParseLogRecord(t *testing.T) {
+	tests := []struct {
+		name         string
+		line         string
+		hasTimestamp bool
+		want         LogRecord
+	}{
+		{
+			name:         "no timestamp",
+			line:         "hello world",
+			hasTimestamp: false,
+			want:         LogRecord{Namespace: "ns", Pod: "pod", Container: "app", Message: "hello world"},
+		},
+		{
+			name:         "well-formed timestamp",
+			line:         "2020-01-02T03:04:05.000000006Z hello world",
+			hasTimestamp: true,
+			want: LogRecord{
+				Namespace: "ns", Pod: "pod", Container: "app",
+				Timestamp: time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC),
+				Message:   "hello world",
+			},
+		},
+		{
+			name:         "malformed timestamp prefix falls back to the raw line",
+			line:         "not-a-timestamp hello world",
+			hasTimestamp: true,
+			want:         LogRecord{Namespace: "ns", Pod: "pod", Container: "app", Message: "not-a-timestamp hello world"},
+		},
+		{
+			name:         "timestamp flag set but line has no space to split on",
+			line:         "hello",
+			hasTimestamp: true,
+			want:         LogRecord{Namespace: "ns", Pod: "pod", Container: "app", Message: "hello"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := parseLogRecord("ns", "pod", "app", test.line, test.hasTimestamp)
+			if got != test.want {
+				t.Errorf("parseLogRecord() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+// ctxAwareStream fakes a log stream that yields one line immediately, then
+// blocks until ctx is canceled — standing in for a real stream whose
+// read unblocks with an error once the request's context is done.
+type ctxAwareStream struct {
+	ctx  context.Context
+	line []byte
+	sent bool
+}
+
+func (s *ctxAwareStream) Read(p []byte) (int, error) {
+	if !s.sent {
+		s.sent = true
+		return copy(p, s.line), nil
+	}
+	<-s.ctx.Done()
+	return 0, s.ctx.Err()
+}
+
+func (s *ctxAwareStream) Close() error { return nil }
+
+func TestReadFollowFansOutAndPreservesRecordsOnCtxCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	restore := getStream
+	getStream = func(r *restclient.Request) (io.ReadCloser, error) {
+		return &ctxAwareStream{ctx: ctx, line: []byte("hello\n")}, nil
+	}
+	defer func() { getStream = restore }()
+
+	client := fake.NewSimpleClientset(
+		&v1.Pod{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "pod-a", Namespace: "default"},
+			Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "app"}}},
+		},
+		&v1.Pod{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "pod-b", Namespace: "default"},
+			Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "app"}}},
+		},
+	)
+
+	a := NewLogAggregator(io.Discard, LogSelector{})
+
+	done := make(chan struct{})
+	var records []LogRecord
+	var err error
+	go func() {
+		records, err = a.Read(ctx, client.CoreV1(), ReadOptions{Follow: true})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("Read() returned before ctx was canceled, want it to keep following both containers")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Read() did not return after ctx was canceled")
+	}
+
+	if err != nil {
+		t.Errorf("Read() error = %v, want nil — canceling ctx is the documented way to end a Follow read", err)
+	}
+	if len(records) != 2 {
+		t.Errorf("Read() returned %d records, want 2 (one per matching container, read concurrently instead of starving each other)", len(records))
+	}
+}
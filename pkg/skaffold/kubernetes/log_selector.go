@@ -0,0 +1,105 @@
+/*
+Copyright 2018 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"github.com/pkg/errors"
+	"k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// ImageMatcher decides whether a container's image should have its logs
+// streamed. Callers plug in their own comparison (e.g. tag-insensitive
+// matching against Skaffold's built-artifact list) instead of relying on
+// an exact string match.
+type ImageMatcher func(image string) bool
+
+// LogSelector narrows down which pods and containers a LogAggregator
+// streams logs from. Namespaces and LabelSelector are pushed down to the
+// apiserver via the watch/list call; ContainerNames and ImageMatcher are
+// evaluated client-side once a matching pod is seen.
+type LogSelector struct {
+	Namespaces     []string
+	LabelSelector  labels.Selector
+	ContainerNames []string
+	ImageMatcher   ImageMatcher
+}
+
+// matchesContainer reports whether c should be streamed, combining the
+// optional container-name allowlist with the image matcher.
+func (s LogSelector) matchesContainer(c v1.Container) bool {
+	if len(s.ContainerNames) > 0 && !containsString(s.ContainerNames, c.Name) {
+		return false
+	}
+	if s.ImageMatcher != nil {
+		return s.ImageMatcher(c.Image)
+	}
+	return true
+}
+
+func containsString(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// listOptions builds the ListOptions used to discover pods matching s,
+// pushing the label selector down to the apiserver.
+func (s LogSelector) listOptions() meta_v1.ListOptions {
+	var listOptions meta_v1.ListOptions
+	if s.LabelSelector != nil {
+		listOptions.LabelSelector = s.LabelSelector.String()
+	}
+	return listOptions
+}
+
+// forEachMatchingContainer lists every pod in s's namespaces (or every
+// namespace if none are set) and invokes fn for each container that
+// passes s.matchesContainer, stopping at the first error from either
+// listing pods or fn itself. Read and DumpLogs both walk pods this way;
+// StreamLogs doesn't, since it discovers pods via PodWatcher instead of
+// polling List.
+func forEachMatchingContainer(client corev1.CoreV1Interface, s LogSelector, fn func(v1.Pod, v1.Container) error) error {
+	namespaces := s.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{""}
+	}
+
+	for _, ns := range namespaces {
+		pods, err := client.Pods(ns).List(s.listOptions())
+		if err != nil {
+			return errors.Wrap(err, "listing pods")
+		}
+
+		for _, p := range pods.Items {
+			for _, c := range p.Spec.Containers {
+				if !s.matchesContainer(c) {
+					continue
+				}
+				if err := fn(p, c); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
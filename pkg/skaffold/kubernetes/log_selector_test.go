@@ -0,0 +1,162 @@
+/*
+Copyright 2018 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"errors"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLogSelectorMatchesContainer(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector LogSelector
+		c        v1.Container
+		want     bool
+	}{
+		{
+			name:     "no filters matches everything",
+			selector: LogSelector{},
+			c:        v1.Container{Name: "app", Image: "gcr.io/proj/app:v1"},
+			want:     true,
+		},
+		{
+			name:     "container name allowlist matches",
+			selector: LogSelector{ContainerNames: []string{"app", "sidecar"}},
+			c:        v1.Container{Name: "app"},
+			want:     true,
+		},
+		{
+			name:     "container name allowlist rejects",
+			selector: LogSelector{ContainerNames: []string{"sidecar"}},
+			c:        v1.Container{Name: "app"},
+			want:     false,
+		},
+		{
+			name:     "image matcher accepts",
+			selector: LogSelector{ImageMatcher: func(image string) bool { return image == "gcr.io/proj/app:v1" }},
+			c:        v1.Container{Name: "app", Image: "gcr.io/proj/app:v1"},
+			want:     true,
+		},
+		{
+			name:     "image matcher rejects",
+			selector: LogSelector{ImageMatcher: func(image string) bool { return false }},
+			c:        v1.Container{Name: "app", Image: "gcr.io/proj/app:v1"},
+			want:     false,
+		},
+		{
+			name: "name allowlist passes but image matcher still rejects",
+			selector: LogSelector{
+				ContainerNames: []string{"app"},
+				ImageMatcher:   func(image string) bool { return false },
+			},
+			c:    v1.Container{Name: "app", Image: "gcr.io/proj/app:v1"},
+			want: false,
+		},
+		{
+			name: "name allowlist rejects before the image matcher is even consulted",
+			selector: LogSelector{
+				ContainerNames: []string{"sidecar"},
+				ImageMatcher:   func(image string) bool { return true },
+			},
+			c:    v1.Container{Name: "app", Image: "gcr.io/proj/app:v1"},
+			want: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.selector.matchesContainer(test.c); got != test.want {
+				t.Errorf("matchesContainer() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestContainsString(t *testing.T) {
+	values := []string{"app", "sidecar"}
+
+	if !containsString(values, "app") {
+		t.Error("containsString() = false for a present value, want true")
+	}
+	if containsString(values, "other") {
+		t.Error("containsString() = true for an absent value, want false")
+	}
+	if containsString(nil, "app") {
+		t.Error("containsString(nil, ...) = true, want false")
+	}
+}
+
+func TestForEachMatchingContainer(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Pod{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "pod-a", Namespace: "ns-a"},
+			Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "app"}, {Name: "sidecar"}}},
+		},
+		&v1.Pod{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "pod-b", Namespace: "ns-b"},
+			Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "app"}}},
+		},
+	)
+
+	selector := LogSelector{Namespaces: []string{"ns-a", "ns-b"}, ContainerNames: []string{"app"}}
+
+	var seen []string
+	err := forEachMatchingContainer(client.CoreV1(), selector, func(p v1.Pod, c v1.Container) error {
+		seen = append(seen, p.Name+"/"+c.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("forEachMatchingContainer() error = %v, want nil", err)
+	}
+	if want := []string{"pod-a/app", "pod-b/app"}; !equalStringSlices(seen, want) {
+		t.Errorf("forEachMatchingContainer() visited %v, want %v (the sidecar container should be filtered out)", seen, want)
+	}
+}
+
+func TestForEachMatchingContainerStopsOnFirstError(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Pod{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "pod-a", Namespace: "ns-a"},
+			Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "app"}}},
+		},
+	)
+
+	wantErr := errors.New("boom")
+	err := forEachMatchingContainer(client.CoreV1(), LogSelector{Namespaces: []string{"ns-a"}}, func(p v1.Pod, c v1.Container) error {
+		return wantErr
+	})
+	if err != wantErr {
+		t.Errorf("forEachMatchingContainer() error = %v, want %v", err, wantErr)
+	}
+}
+
+func equalStringSlices(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
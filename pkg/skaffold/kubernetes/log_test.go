@@ -0,0 +1,56 @@
+/*
+Copyright 2018 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestStreamKey(t *testing.T) {
+	if got, want := streamKey(types.UID("pod-a"), "web"), "pod-a/web"; got != want {
+		t.Errorf("streamKey() = %q, want %q", got, want)
+	}
+
+	if streamKey(types.UID("pod-a"), "web") == streamKey(types.UID("pod-a"), "sidecar") {
+		t.Error("streamKey() should differ for different containers on the same pod")
+	}
+}
+
+func TestStartStopStream(t *testing.T) {
+	a := &LogAggregator{activeStreams: make(map[string]context.CancelFunc)}
+
+	canceled := false
+	cancel := func() { canceled = true }
+
+	if !a.startStream("pod-a/web", cancel) {
+		t.Fatal("startStream() = false for a key with no active stream, want true")
+	}
+	if a.startStream("pod-a/web", cancel) {
+		t.Error("startStream() = true for an already-active key, want false so callers don't start a second goroutine for it")
+	}
+
+	a.stopStream("pod-a/web")
+	if !canceled {
+		t.Error("stopStream() did not invoke the stream's cancel func")
+	}
+	if !a.startStream("pod-a/web", cancel) {
+		t.Error("startStream() = false after stopStream() forgot the key, want true")
+	}
+}
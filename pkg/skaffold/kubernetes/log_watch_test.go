@@ -0,0 +1,93 @@
+/*
+Copyright 2018 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"errors"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestIsPodReady(t *testing.T) {
+	tests := []struct {
+		name string
+		pod  *v1.Pod
+		want bool
+	}{
+		{"no conditions", &v1.Pod{}, false},
+		{"ready", podWithCondition(v1.PodReady, v1.ConditionTrue), true},
+		{"not ready", podWithCondition(v1.PodReady, v1.ConditionFalse), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isPodReady(test.pod); got != test.want {
+				t.Errorf("isPodReady() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func podWithCondition(t v1.PodConditionType, status v1.ConditionStatus) *v1.Pod {
+	return &v1.Pod{Status: v1.PodStatus{Conditions: []v1.PodCondition{{Type: t, Status: status}}}}
+}
+
+func TestIsContainerDead(t *testing.T) {
+	pod := &v1.Pod{Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{
+		{Name: "terminated-no-restart", State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{}}, RestartCount: 0},
+		{Name: "terminated-restarted", State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{}}, RestartCount: 2},
+		{Name: "running", State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}},
+	}}}
+
+	tests := []struct {
+		container string
+		want      bool
+	}{
+		{"terminated-no-restart", true},
+		{"terminated-restarted", false},
+		{"running", false},
+		{"unknown", false},
+	}
+	for _, test := range tests {
+		if got := isContainerDead(pod, test.container); got != test.want {
+			t.Errorf("isContainerDead(%q) = %v, want %v", test.container, got, test.want)
+		}
+	}
+}
+
+func TestIsRecoverable(t *testing.T) {
+	gr := schema.GroupResource{Group: "", Resource: "pods"}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"forbidden", apierrors.NewForbidden(gr, "pod", errors.New("denied")), false},
+		{"not found", apierrors.NewNotFound(gr, "pod"), false},
+		{"other", apierrors.NewInternalError(errors.New("boom")), true},
+		{"generic", errors.New("connection reset"), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isRecoverable(test.err); got != test.want {
+				t.Errorf("isRecoverable(%v) = %v, want %v", test.err, got, test.want)
+			}
+		})
+	}
+}
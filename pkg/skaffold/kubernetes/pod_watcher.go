@@ -0,0 +1,167 @@
+/*
+Copyright 2018 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
+)
+
+// PodEvent is a single ADDED/MODIFIED/DELETED notification about a pod.
+type PodEvent struct {
+	Type watch.EventType
+	Pod  *v1.Pod
+}
+
+// PodWatcher watches one or more namespaces for pod changes and delivers
+// them on a single channel, using a retry watcher per namespace. A
+// RetryWatcher doesn't actually recover from a "410 Gone"/expired
+// resource version: it sends one terminal Error event and closes its
+// ResultChan for good. PodWatcher compensates by recreating the
+// RetryWatcher whenever that happens, so a long-lived skaffold dev
+// session keeps discovering pods instead of going silent.
+type PodWatcher struct {
+	client      corev1.CoreV1Interface
+	namespaces  []string
+	listOptions meta_v1.ListOptions
+
+	stopCh chan struct{}
+}
+
+// NewPodWatcher creates a PodWatcher for the given namespaces. An empty
+// list watches pods across the whole cluster.
+func NewPodWatcher(client corev1.CoreV1Interface, namespaces []string, listOptions meta_v1.ListOptions) *PodWatcher {
+	if len(namespaces) == 0 {
+		namespaces = []string{""}
+	}
+	return &PodWatcher{
+		client:      client,
+		namespaces:  namespaces,
+		listOptions: listOptions,
+		stopCh:      make(chan struct{}),
+	}
+}
+
+// Start begins watching every namespace and returns a channel merging all
+// of their PodEvents. The channel is closed once Stop is called.
+func (w *PodWatcher) Start() (<-chan PodEvent, error) {
+	events := make(chan PodEvent)
+	var wg sync.WaitGroup
+
+	for _, ns := range w.namespaces {
+		ns := ns
+		watcher, err := w.newRetryWatcher(ns)
+		if err != nil {
+			return nil, errors.Wrapf(err, "starting pod watch for namespace %q", ns)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.watchNamespace(ns, watcher, events)
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+
+	return events, nil
+}
+
+func (w *PodWatcher) newRetryWatcher(ns string) (watch.Interface, error) {
+	return watchtools.NewRetryWatcher("1", &cache.ListWatch{
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			options.LabelSelector = w.listOptions.LabelSelector
+			options.FieldSelector = w.listOptions.FieldSelector
+			return w.client.Pods(ns).Watch(options)
+		},
+	})
+}
+
+// watchNamespace drains watcher until it gives up for good (a 410
+// Gone/expired resource version, or its ResultChan otherwise closes), then
+// creates a fresh RetryWatcher and keeps going. It only returns once
+// stopCh fires.
+func (w *PodWatcher) watchNamespace(ns string, watcher watch.Interface, events chan<- PodEvent) {
+	for {
+		if w.drainWatch(watcher, ns, events) {
+			return
+		}
+
+		logrus.Infof("Pod watch for namespace %q closed, restarting", ns)
+		for {
+			next, err := w.newRetryWatcher(ns)
+			if err == nil {
+				watcher = next
+				break
+			}
+			logrus.Warnf("Restarting pod watch for namespace %q: %s", ns, err)
+			select {
+			case <-time.After(streamRetryDelay):
+			case <-w.stopCh:
+				return
+			}
+		}
+	}
+}
+
+// drainWatch reads events off watcher until its ResultChan closes or a
+// terminal watch.Error event arrives, forwarding PodEvents onto events.
+// It reports whether the caller should stop entirely (stopCh fired) as
+// opposed to restarting the watch.
+func (w *PodWatcher) drainWatch(watcher watch.Interface, ns string, events chan<- PodEvent) (stop bool) {
+	defer watcher.Stop()
+	for {
+		select {
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return false
+			}
+			if event.Type == watch.Error {
+				logrus.Infof("Pod watch for namespace %q received a terminal error event: %v", ns, event.Object)
+				return false
+			}
+			pod, ok := event.Object.(*v1.Pod)
+			if !ok {
+				continue
+			}
+			select {
+			case events <- PodEvent{Type: event.Type, Pod: pod}:
+			case <-w.stopCh:
+				return true
+			}
+		case <-w.stopCh:
+			return true
+		}
+	}
+}
+
+// Stop shuts every namespace watch down.
+func (w *PodWatcher) Stop() {
+	close(w.stopCh)
+}